@@ -0,0 +1,51 @@
+package changelog
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Section declares one changelog section and the Conventional Commit
+// types that belong in it, e.g. {Name: "Features", Types: ["feat"]}.
+type Section struct {
+	Name  string   `yaml:"name"`
+	Types []string `yaml:"types"`
+}
+
+// Config is the parsed content of .deploy.yaml.
+type Config struct {
+	Sections []Section `yaml:"sections"`
+}
+
+// DefaultConfig is used when no .deploy.yaml is present.
+func DefaultConfig() *Config {
+	return &Config{
+		Sections: []Section{
+			{Name: "Breaking Changes", Types: []string{"breaking"}},
+			{Name: "Features", Types: []string{"feat"}},
+			{Name: "Fixes", Types: []string{"fix"}},
+			{Name: "Other", Types: nil},
+		},
+	}
+}
+
+// LoadConfig reads .deploy.yaml from path, falling back to DefaultConfig
+// if the file doesn't exist.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Sections) == 0 {
+		return DefaultConfig(), nil
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,150 @@
+// Package changelog renders release changelogs from a Go template plus a
+// section config, replacing the previous hard-coded template and flat
+// commit list.
+package changelog
+
+import (
+	"bytes"
+	_ "embed"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/tsuyoshiwada/go-gitlog"
+
+	"deploy/sv"
+)
+
+//go:embed templates/default.tpl
+var defaultTemplate string
+
+// TemplatePath is where a project can check in its own changelog template.
+const TemplatePath = ".deploy/changelog.tpl"
+
+// Group is a named set of commits belonging to one changelog Section.
+type Group struct {
+	Name    string
+	Commits []*gitlog.Commit
+}
+
+// Renderer renders a changelog from commits grouped by Config's sections,
+// using a Go template loaded from TemplatePath (or the embedded default).
+type Renderer struct {
+	tmpl *template.Template
+	cfg  *Config
+}
+
+// NewRenderer loads the template at tplPath, falling back to the embedded
+// default template if tplPath doesn't exist.
+func NewRenderer(tplPath string, cfg *Config) (*Renderer, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	body, err := os.ReadFile(tplPath)
+	if os.IsNotExist(err) {
+		body = []byte(defaultTemplate)
+	} else if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("changelog").Funcs(templateFuncs).Parse(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Renderer{tmpl: tmpl, cfg: cfg}, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"getsection": func(sections []Group, name string) Group {
+		for _, s := range sections {
+			if s.Name == name {
+				return s
+			}
+		}
+		return Group{Name: name}
+	},
+	"timefmt": func(t time.Time, layout string) string {
+		return t.Format(layout)
+	},
+	"shortHash": func(hash string) string {
+		if len(hash) > 7 {
+			return hash[:7]
+		}
+		return hash
+	},
+	"commitURL": func(hash string) string {
+		return "../../commit/" + hash
+	},
+}
+
+// GroupCommits buckets commits into the renderer's configured sections, in
+// config order, dropping empty sections. A commit whose Conventional
+// Commit type isn't listed under any section - including a commit that
+// isn't a Conventional Commit at all, whose type is "" - falls into the
+// first section with no declared types (the catch-all, usually "Other").
+// If no section is configured as a catch-all, such commits are dropped.
+func (r *Renderer) GroupCommits(commits []*gitlog.Commit) []Group {
+	typeToSection := map[string]string{}
+	var catchAll string
+	for _, s := range r.cfg.Sections {
+		if len(s.Types) == 0 {
+			if catchAll == "" {
+				catchAll = s.Name
+			}
+			continue
+		}
+		for _, t := range s.Types {
+			typeToSection[t] = s.Name
+		}
+	}
+
+	buckets := map[string][]*gitlog.Commit{}
+	for _, c := range commits {
+		typ, bump := sv.ParseCommit(c.Subject, c.Body)
+		if bump == sv.BumpMajor {
+			if section, ok := typeToSection["breaking"]; ok {
+				buckets[section] = append(buckets[section], c)
+				continue
+			}
+		}
+		if section, ok := typeToSection[typ]; ok {
+			buckets[section] = append(buckets[section], c)
+			continue
+		}
+		if catchAll != "" {
+			buckets[catchAll] = append(buckets[catchAll], c)
+		}
+	}
+
+	var groups []Group
+	for _, s := range r.cfg.Sections {
+		if len(buckets[s.Name]) == 0 {
+			continue
+		}
+		groups = append(groups, Group{Name: s.Name, Commits: buckets[s.Name]})
+	}
+	return groups
+}
+
+// Render groups commits per the renderer's config and executes the
+// template against sections, commits and meta (extra top-level template
+// data such as ReleaseTag and CreatedAt).
+func (r *Renderer) Render(commits []*gitlog.Commit, meta map[string]interface{}) (string, error) {
+	sections := r.GroupCommits(commits)
+
+	data := map[string]interface{}{
+		"Sections": sections,
+		"Commits":  commits,
+	}
+	for k, v := range meta {
+		data[k] = v
+	}
+
+	var b bytes.Buffer
+	if err := r.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,87 @@
+package changelog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsuyoshiwada/go-gitlog"
+)
+
+func testCommits() []*gitlog.Commit {
+	author := func(name string, date time.Time) *gitlog.Author {
+		return &gitlog.Author{Name: name, Date: date}
+	}
+	d := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return []*gitlog.Commit{
+		{
+			Hash:    &gitlog.Hash{Long: "1111111111111111111111111111111111111111", Short: "1111111"},
+			Subject: "feat(api): add webhook endpoint",
+			Author:  author("Ada", d),
+		},
+		{
+			Hash:    &gitlog.Hash{Long: "2222222222222222222222222222222222222222", Short: "2222222"},
+			Subject: "fix(api)!: reject malformed payloads",
+			Body:    "BREAKING CHANGE: payload validation is now strict",
+			Author:  author("Grace", d),
+		},
+		{
+			Hash:    &gitlog.Hash{Long: "3333333333333333333333333333333333333333", Short: "3333333"},
+			Subject: "chore: bump dependencies",
+			Author:  author("Linus", d),
+		},
+	}
+}
+
+func renderWithTemplate(t *testing.T, tplBody string) string {
+	t.Helper()
+	tplPath := filepath.Join(t.TempDir(), "changelog.tpl")
+	if tplBody != "" {
+		if err := os.WriteFile(tplPath, []byte(tplBody), 0o644); err != nil {
+			t.Fatalf("writing template: %v", err)
+		}
+	}
+
+	r, err := NewRenderer(tplPath, DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	out, err := r.Render(testCommits(), map[string]interface{}{
+		"ReleaseTag": "v1.2.0",
+		"CreatedAt":  time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	return out
+}
+
+func assertGolden(t *testing.T, goldenName string, got string) {
+	t.Helper()
+	goldenPath := filepath.Join("testdata", goldenName)
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("reading golden file %s: %v", goldenPath, err)
+	}
+	if got != string(want) {
+		t.Errorf("render output for %s mismatched golden file\ngot:\n%s\nwant:\n%s", goldenName, got, want)
+	}
+}
+
+func TestRenderer_DefaultTemplate(t *testing.T) {
+	out := renderWithTemplate(t, "")
+	assertGolden(t, "default.golden", out)
+}
+
+func TestRenderer_CustomTemplate(t *testing.T) {
+	custom := `# {{ .ReleaseTag }}
+{{ with getsection .Sections "Breaking Changes" }}Breaking: {{ len .Commits }}
+{{ end -}}
+{{ with getsection .Sections "Features" }}Features: {{ len .Commits }}
+{{ end -}}
+`
+	out := renderWithTemplate(t, custom)
+	assertGolden(t, "custom.golden", out)
+}
@@ -0,0 +1,73 @@
+// Package gitx isolates release operations from the developer's working
+// copy by running them inside a throwaway git worktree.
+package gitx
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Worktree is a temporary checkout of the repository's default branch,
+// used so tagging/pushing doesn't require (or touch) a clean working copy.
+type Worktree struct {
+	// Dir is the path to the worktree's root, suitable for passing as the
+	// working directory of any git command.
+	Dir string
+}
+
+// DefaultBranch returns the repository's default branch: the branch
+// origin/HEAD points at, falling back to main or master if origin/HEAD
+// isn't set up locally.
+func DefaultBranch() (string, error) {
+	out, err := exec.Command("git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output()
+	if err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(string(out)), "origin/"), nil
+	}
+
+	for _, candidate := range []string{"main", "master"} {
+		if err := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+candidate).Run(); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("could not detect default branch, and no main/master branch exists")
+}
+
+// New checks out the default branch into a fresh temporary worktree.
+func New() (*Worktree, error) {
+	branch, err := DefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "deploy-worktree-")
+	if err != nil {
+		return nil, err
+	}
+	// `git worktree add` requires the target directory not to exist yet.
+	if err := os.Remove(dir); err != nil {
+		return nil, err
+	}
+
+	// --detach: `branch` may already be checked out in the caller's primary
+	// working copy (the common case --isolated exists for), and git refuses
+	// to check out the same branch in two worktrees at once.
+	if out, err := exec.Command("git", "worktree", "add", "--detach", dir, branch).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git worktree add --detach %s %s: %w: %s", dir, branch, err, out)
+	}
+
+	return &Worktree{Dir: dir}, nil
+}
+
+// Close removes the worktree and prunes its metadata. Safe to call via
+// defer whether or not the release in it succeeded.
+func (w *Worktree) Close() error {
+	if out, err := exec.Command("git", "worktree", "remove", "--force", w.Dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree remove %s: %w: %s", w.Dir, err, out)
+	}
+	if out, err := exec.Command("git", "worktree", "prune").CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree prune: %w: %s", err, out)
+	}
+	return nil
+}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"strings"
-	"text/template"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
@@ -17,101 +14,100 @@ import (
 	semv "github.com/linyows/git-semv"
 	"github.com/tsuyoshiwada/go-gitlog"
 	cli "github.com/urfave/cli/v2"
+
+	"deploy/changelog"
+	"deploy/internal/gitx"
+	"deploy/monorepo"
+	"deploy/publish"
+	"deploy/repo"
+	"deploy/sv"
+	"deploy/workflow"
 )
 
-func RepoIsClean() (bool, error) {
-	res, err := exec.Command("git", "status", "-s").Output()
+// commitsSince returns the commits between fromTag and HEAD, resolved in
+// dir (the process's working directory if dir == ""). go-gitlog always
+// runs `git log` against the process's own working directory, so when
+// dir points at an isolated worktree we run the lookup from inside it.
+func commitsSince(r repo.Repo, dir string, fromTag string) ([]*gitlog.Commit, error) {
+	restore, err := pushd(dir)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return len(res) == 0, nil
-}
+	defer restore()
 
-func RepoIsMasterOrMain() (bool, error) {
-	res, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
-	if err != nil {
-		return false, err
+	git := gitlog.New(&gitlog.Config{})
+	if fromTag == "" {
+		return git.Log(nil, nil)
 	}
-	branchName := strings.Trim(string(res), "\n")
-	if branchName == "master" || branchName == "main" {
-		return true, nil
+	lastCommit, err := r.LastCommit()
+	if err != nil {
+		return nil, err
 	}
-	return false, nil
+	return git.Log(&gitlog.RevRange{
+		Old: fromTag,
+		New: lastCommit,
+	}, nil)
 }
 
-func GetLastCommit() (string, error) {
-	res, err := exec.Command("git", "rev-parse", "HEAD").Output()
+// pushd changes the process's working directory to dir and returns a
+// func that restores it. dir == "" is a no-op.
+func pushd(dir string) (func(), error) {
+	if dir == "" {
+		return func() {}, nil
+	}
+	prev, err := os.Getwd()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	commitName := strings.Trim(string(res), "\n")
-	return commitName, nil
-}
-
-func RepoFetchTags() error {
-	_, err := exec.Command("git", "fetch", "--tags", "--force").Output()
-	return err
-}
-
-func CheckIfTagExists(tagName string) error {
-	_, err := exec.Command("git", "rev-parse", tagName).Output()
-	return err
+	if err := os.Chdir(dir); err != nil {
+		return nil, err
+	}
+	return func() { _ = os.Chdir(prev) }, nil
 }
 
-func RepoCreateTag(tagName string) error {
-	_, err := exec.Command("git", "tag", tagName).Output()
+// inferNextVersion walks the commits since fromTag and returns the semver
+// bump (patch/minor/major) implied by their Conventional Commit subjects.
+// If no Conventional Commits are found, it returns an error unless
+// allowEmpty is set, in which case it falls back to a patch bump.
+func inferNextVersion(r repo.Repo, dir string, fromTag string, allowEmpty bool) (string, error) {
+	commits, err := commitsSince(r, dir, fromTag)
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = exec.Command("git", "push", "origin", tagName).Output()
-	return err
-}
-
-func GenerateGithubRelease(releaseTag string, changeLog string) error {
-	_, err := exec.Command("gh", "release", "create", releaseTag, "--notes", changeLog, "-t", releaseTag).Output()
-	return err
-}
-
-func generateMarkdownChangelog(fromTag string, untilTag string) (string, error) {
-	git := gitlog.New(&gitlog.Config{})
-	var commits []*gitlog.Commit
-	var err error
-	if fromTag == "" {
-		commits, err = git.Log(nil, nil)
-	} else {
-		lastCommit, err := GetLastCommit()
-		if err != nil {
-			return "", err
-		}
-		commits, err = git.Log(&gitlog.RevRange{
-			Old: fromTag,
-			New: lastCommit,
-		}, nil)
-		if err != nil {
-			return "", err
+	bump, found := sv.Infer(commits)
+	if !found {
+		if allowEmpty {
+			return string(sv.BumpPatch), nil
 		}
+		return "", errors.New("no conventional commits found since latest tag, pass --allow-empty to deploy anyway")
 	}
+	return string(bump), nil
+}
 
+func generateMarkdownChangelog(r repo.Repo, dir string, fromTag string, untilTag string) (string, error) {
+	commits, err := commitsSince(r, dir, fromTag)
 	if err != nil {
 		return "", err
 	}
-	tmplData := map[string]interface{}{
-		"ReleaseTag": untilTag,
-		"CreatedAt":  time.Now(),
-		"Commits":    commits,
-	}
 
-	var b bytes.Buffer
-	err = mdTmpl.Execute(&b, tmplData)
+	cfg, err := changelog.LoadConfig(".deploy.yaml")
 	if err != nil {
 		return "", err
 	}
-	return b.String(), nil
+	renderer, err := changelog.NewRenderer(changelog.TemplatePath, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return renderer.Render(commits, map[string]interface{}{
+		"ReleaseTag": untilTag,
+		"CreatedAt":  time.Now(),
+	})
 }
 
 func validateVersion(version string) error {
-	valid := []string{"patch", "minor", "major"}
-	err := errors.New("Version has to be oneOf: patch, minor or major")
+	valid := []string{"auto", "patch", "minor", "major"}
+	err := errors.New("Version has to be oneOf: auto, patch, minor or major")
 	if version == "" {
 		return err
 	}
@@ -123,127 +119,317 @@ func validateVersion(version string) error {
 	return err
 }
 
-func deployNewVersion(nextVersion string, buildName string) error {
+// releaseWorkflow builds the check-clean -> ... -> publish-release DAG
+// that deployNewVersion runs. isolated skips the working-copy checks
+// (the worktree is clean and on the default branch by construction).
+func releaseWorkflow(r repo.Repo, dir string, nextVersion string, buildName string, allowEmpty bool, isolated bool, yes bool, publisherName string, publisherOpts publish.Options) *workflow.Workflow {
+	return &workflow.Workflow{Tasks: []workflow.Task{
+		{Name: "check-clean", Run: func(s *workflow.State) error {
+			if isolated {
+				return nil
+			}
+			isClean, err := r.IsClean()
+			if err != nil {
+				return err
+			}
+			if !isClean {
+				return errors.New("Please make sure there are no changes")
+			}
+			return nil
+		}},
+		{Name: "check-branch", DependsOn: []string{"check-clean"}, Run: func(s *workflow.State) error {
+			if isolated {
+				return nil
+			}
+			isMasterOrMain, err := r.IsMasterOrMain()
+			if err != nil {
+				return err
+			}
+			if !isMasterOrMain {
+				return errors.New("Releases are allowed to tag from master/main branch")
+			}
+			return nil
+		}},
+		{Name: "fetch-tags", DependsOn: []string{"check-branch"}, Run: func(s *workflow.State) error {
+			return r.FetchTags()
+		}},
+		{Name: "compute-next", DependsOn: []string{"fetch-tags"}, Run: func(s *workflow.State) error {
+			latest, err := semv.Latest()
+			if err != nil {
+				return err
+			}
+			if buildName != "" {
+				_, _ = latest.Build(buildName)
+				// fall back to the latest without a build tag if none
+				// exists for this build name yet
+				if err := r.TagExists(latest.String()); err != nil {
+					latest, err = semv.Latest()
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			version := nextVersion
+			if version == "auto" {
+				version, err = inferNextVersion(r, dir, latest.String(), allowEmpty)
+				if err != nil {
+					return err
+				}
+			}
+
+			next := latest.Next(version)
+			if buildName != "" {
+				_, _ = next.Build(buildName)
+			}
+
+			s.Outputs["latestTag"] = latest.String()
+			s.Outputs["nextReleaseTag"] = next.String()
+			return nil
+		}},
+		{Name: "build-changelog", DependsOn: []string{"compute-next"}, Run: func(s *workflow.State) error {
+			latestTag, _ := s.Outputs["latestTag"].(string)
+			nextReleaseTag, _ := s.Outputs["nextReleaseTag"].(string)
+
+			chglog, err := generateMarkdownChangelog(r, dir, latestTag, nextReleaseTag)
+			if err != nil {
+				return err
+			}
+			s.Outputs["changelog"] = chglog
+
+			fmt.Println(string("\nCHANGELOG:\n"))
+			fmt.Println(string(markdown.Render(chglog, 80, 6)))
+			return nil
+		}},
+		{Name: "confirm", DependsOn: []string{"build-changelog"}, Run: func(s *workflow.State) error {
+			if yes {
+				return nil
+			}
+			nextReleaseTag, _ := s.Outputs["nextReleaseTag"].(string)
+
+			deploy := false
+			prompt := &survey.Confirm{
+				Message: "Do you want to deploy: " + nextReleaseTag + " ?",
+				Default: true,
+			}
+			if err := survey.AskOne(prompt, &deploy); err != nil {
+				return err
+			}
+			if !deploy {
+				return workflow.ErrAborted
+			}
+			return nil
+		}},
+		{Name: "create-tag", DependsOn: []string{"confirm"}, Run: func(s *workflow.State) error {
+			nextReleaseTag, _ := s.Outputs["nextReleaseTag"].(string)
+			if err := r.TagExists(nextReleaseTag); err == nil {
+				return nil // already tagged locally by a prior, interrupted run
+			}
+			return r.CreateTag(nextReleaseTag, nextReleaseTag)
+		}},
+		{Name: "push-tag", DependsOn: []string{"create-tag"}, Run: func(s *workflow.State) error {
+			nextReleaseTag, _ := s.Outputs["nextReleaseTag"].(string)
+			return r.PushTag(nextReleaseTag)
+		}},
+		{Name: "publish-release", DependsOn: []string{"push-tag"}, Run: func(s *workflow.State) error {
+			nextReleaseTag, _ := s.Outputs["nextReleaseTag"].(string)
+			chglog, _ := s.Outputs["changelog"].(string)
+
+			publisher, err := publish.New(publisherName, publisherOpts)
+			if err != nil {
+				return err
+			}
+			exists, err := publisher.ReleaseExists(context.Background(), nextReleaseTag)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return nil // already published by a prior, interrupted run
+			}
+			return publisher.CreateRelease(context.Background(), nextReleaseTag, nextReleaseTag, chglog)
+		}},
+	}}
+}
+
+func deployNewVersion(nextVersion string, buildName string, allowEmpty bool, publisherName string, publisherOpts publish.Options, isolated bool, sign bool, yes bool, resumeID string) error {
 	l := kemba.New("deloy")
 
 	l.Printf("Starting deployment %s for %s", nextVersion, buildName)
 
-	// Check if repo is clean
-	l.Println("Checking if repo is clean")
-	isClean, err := RepoIsClean()
-	if err != nil {
-		return err
-	}
-	if !isClean {
-		return errors.New("Please make sure there are no changes")
+	var dir string
+	if isolated {
+		l.Println("Isolating release in a temporary worktree")
+		wt, err := gitx.New()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := wt.Close(); err != nil {
+				log.Printf("cleaning up worktree %s: %v", wt.Dir, err)
+			}
+		}()
+		dir = wt.Dir
 	}
-	l.Println("Repo is clean")
 
-	// Check if we are on master
-	l.Println("Check if repo is master or main")
-	isMasterOrMain, err := RepoIsMasterOrMain()
+	r, err := repo.Open(dir, repo.Options{Sign: sign})
 	if err != nil {
 		return err
 	}
-	if !isMasterOrMain {
-		return errors.New("Releases are allowed to tag from master/main branch")
-	}
 
-	// Fetch latest remote tags
-	l.Println("Fetching Tags")
-	err = RepoFetchTags()
-	if err != nil {
-		return err
+	var state *workflow.State
+	if resumeID != "" {
+		l.Printf("Resuming release %s", resumeID)
+		s, err := workflow.LoadState(resumeID)
+		if err != nil {
+			return err
+		}
+		state = s
+	} else {
+		commit, err := r.LastCommit()
+		if err != nil {
+			return err
+		}
+		state = workflow.NewState(commit)
 	}
 
-	// Get the latest Tag
-	l.Println("Getting Latest Tag")
-	latest, err := semv.Latest()
-	if err != nil {
-		return err
-	}
-	// add the build name to the Tag
-	if buildName != "" {
-		_, _ = latest.Build(buildName)
-		// we need to check if the latest with build tag exists
-		// if not fall back to the latest that does
-		l.Println("Checking of last tag exists")
-		err := CheckIfTagExists(latest.String())
-		if err != nil {
-			latest, err = semv.Latest()
-			if err != nil {
-				return err
-			}
+	wf := releaseWorkflow(r, dir, nextVersion, buildName, allowEmpty, isolated, yes, publisherName, publisherOpts)
+	runErr := wf.Run(state)
+
+	if nextReleaseTag, ok := state.Outputs["nextReleaseTag"].(string); ok && state.ID != nextReleaseTag {
+		if err := state.Rename(nextReleaseTag); err != nil {
+			l.Printf("renaming state file to %s: %v", nextReleaseTag, err)
 		}
 	}
 
-	// Get the next Tag
-	next := latest.Next(nextVersion)
-	if buildName != "" {
-		_, _ = next.Build(buildName)
+	if runErr == workflow.ErrAborted {
+		return nil
+	}
+	if runErr != nil {
+		return fmt.Errorf("%w (resume with: deploy --resume %s)", runErr, state.ID)
 	}
 
-	nextReleaseTag := next.String()
+	l.Println("done deploying")
+	return nil
+}
 
-	// generate changelog
-	l.Printf("Generating markdown - fromTag: %s untilTag: %s", latest.String(), nextReleaseTag)
-	chglog, err := generateMarkdownChangelog(latest.String(), nextReleaseTag)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string("\nCHANGELOG:\n"))
-	result := markdown.Render(string(chglog), 80, 6)
-	fmt.Println(string(result))
-	deploy := false
-	prompt := &survey.Confirm{
-		Message: "Do you want to deploy: " + nextReleaseTag + " ?",
-		Default: true,
+func planCommand() *cli.Command {
+	var configPath string
+	var yes bool
+	var publisherName string
+	var draft bool
+	var prerelease bool
+	return &cli.Command{
+		Name:  "plan",
+		Usage: "tag and release every changed module in a monorepo, in dependency order",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "config",
+				Aliases:     []string{"c"},
+				Value:       "deploy.yaml",
+				Usage:       "Path to the monorepo module config",
+				Destination: &configPath,
+			},
+			&cli.BoolFlag{
+				Name:        "yes",
+				Aliases:     []string{"y"},
+				Usage:       "Skip the confirmation prompt",
+				Destination: &yes,
+			},
+			publisherFlag(&publisherName),
+			draftFlag(&draft),
+			prereleaseFlag(&prerelease),
+		},
+		Action: func(c *cli.Context) error {
+			return runPlan(configPath, yes, publisherName, publish.Options{Draft: draft, Prerelease: prerelease})
+		},
 	}
+}
 
-	err = survey.AskOne(prompt, &deploy)
+func runPlan(configPath string, yes bool, publisherName string, publisherOpts publish.Options) error {
+	cfg, err := monorepo.LoadConfig(configPath)
 	if err != nil {
 		return err
 	}
 
-	if !deploy {
-		return nil
-	}
-	l.Println("Generating and pushing tag")
-	err = RepoCreateTag(nextReleaseTag)
+	publisher, err := publish.New(publisherName, publisherOpts)
 	if err != nil {
 		return err
 	}
-	l.Println("Generating github release")
-	err = GenerateGithubRelease(nextReleaseTag, chglog)
+
+	plans, err := monorepo.Build(cfg, publisher)
 	if err != nil {
 		return err
 	}
 
-	l.Println("done deploying")
+	dirty := monorepo.Dirty(plans)
+	if len(dirty) == 0 {
+		fmt.Println("No modules changed since their last release, nothing to do")
+		return nil
+	}
+
+	fmt.Println(string(markdown.Render(monorepo.Preview(dirty), 80, 6)))
+
+	if !yes {
+		deploy := false
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Tag and release %d module(s)?", len(dirty)),
+			Default: true,
+		}
+		if err := survey.AskOne(prompt, &deploy); err != nil {
+			return err
+		}
+		if !deploy {
+			return nil
+		}
+	}
+
+	for _, p := range dirty {
+		if err := monorepo.Release(p, publisher); err != nil {
+			return fmt.Errorf("releasing module %s: %w", p.Module.Name, err)
+		}
+	}
 
 	return nil
 }
 
-var (
-	mdTmpl  *template.Template
-	tmplStr = `## {{ .ReleaseTag }} {{.CreatedAt.Format "02.01.2006"}}
-{{ range .Commits -}}
-- [{{.Hash.Short}}](../../commit/{{.Hash.Long}}) {{ .Subject }} ({{ .Author.Name}}, {{.Author.Date.Format "02.01.2006"}})
-{{ end }}
-`
-)
+// publisherFlag, draftFlag and prereleaseFlag are shared between the root
+// command and `deploy plan` so both release a module the same way.
+func publisherFlag(dest *string) *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:        "publisher",
+		Value:       "auto",
+		Usage:       "Where to publish the release: auto, github, gitlab, gitea, dry-run. auto detects the forge from the origin remote",
+		Destination: dest,
+	}
+}
 
-func init() {
-	var err error
-	mdTmpl, err = template.New("md-changelog").Parse(tmplStr)
-	if err != nil {
-		log.Fatal(err)
+func draftFlag(dest *bool) *cli.BoolFlag {
+	return &cli.BoolFlag{
+		Name:        "draft",
+		Usage:       "Publish the release as a draft",
+		Destination: dest,
+	}
+}
+
+func prereleaseFlag(dest *bool) *cli.BoolFlag {
+	return &cli.BoolFlag{
+		Name:        "prerelease",
+		Usage:       "Publish the release as a prerelease",
+		Destination: dest,
 	}
 }
 
 func main() {
 	var buildName string
 	var version string
+	var allowEmpty bool
+	var publisherName string
+	var draft bool
+	var prerelease bool
+	var isolated bool
+	var sign bool
+	var yes bool
+	var resumeID string
 	app := &cli.App{
 		Usage:     "a monorepo deploy helper",
 		UsageText: "deploy --version minor --name myservice",
@@ -257,8 +443,8 @@ func main() {
 			&cli.StringFlag{
 				Name:        "version",
 				Aliases:     []string{"v"},
-				Value:       "patch",
-				Usage:       "Version you want to deploy, can be: patch, minor, major",
+				Value:       "auto",
+				Usage:       "Version you want to deploy, can be: auto, patch, minor, major. auto infers the bump from Conventional Commits since the last tag",
 				Destination: &version,
 			},
 			&cli.StringFlag{
@@ -267,13 +453,47 @@ func main() {
 				Usage:       "Optional: Service prefix for the tag",
 				Destination: &buildName,
 			},
+			&cli.BoolFlag{
+				Name:        "allow-empty",
+				Usage:       "When --version=auto, fall back to a patch bump instead of erroring if no conventional commits are found",
+				Destination: &allowEmpty,
+			},
+			publisherFlag(&publisherName),
+			draftFlag(&draft),
+			prereleaseFlag(&prerelease),
+			&cli.BoolFlag{
+				Name:        "isolated",
+				Usage:       "Run the release from a temporary worktree of the default branch, instead of requiring a clean working copy",
+				Destination: &isolated,
+			},
+			&cli.BoolFlag{
+				Name:        "sign",
+				Aliases:     []string{"s"},
+				Usage:       "GPG-sign the release tag using your configured signing key",
+				Destination: &sign,
+			},
+			&cli.BoolFlag{
+				Name:        "yes",
+				Aliases:     []string{"y"},
+				Usage:       "Skip the confirmation prompt",
+				Destination: &yes,
+			},
+			&cli.StringFlag{
+				Name:        "resume",
+				Usage:       "Resume a previously interrupted release by its state ID (the commit it was cut from, or its release tag)",
+				Destination: &resumeID,
+			},
 		},
 		Action: func(c *cli.Context) error {
-			err := validateVersion(version)
-			if err != nil {
-				return err
+			if resumeID == "" {
+				if err := validateVersion(version); err != nil {
+					return err
+				}
 			}
-			return deployNewVersion(version, buildName)
+			return deployNewVersion(version, buildName, allowEmpty, publisherName, publish.Options{Draft: draft, Prerelease: prerelease}, isolated, sign, yes, resumeID)
+		},
+		Commands: []*cli.Command{
+			planCommand(),
 		},
 	}
 
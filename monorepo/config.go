@@ -0,0 +1,100 @@
+// Package monorepo plans and executes multi-module releases out of a
+// single monorepo, tagging and releasing each changed module in
+// dependency order in one `deploy plan` run.
+package monorepo
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Module describes one releasable service/library inside the monorepo.
+type Module struct {
+	Name            string   `yaml:"name"`
+	Path            string   `yaml:"path"`
+	DependsOn       []string `yaml:"dependsOn"`
+	VersionStrategy string   `yaml:"versionStrategy"`
+}
+
+// Config is the parsed content of deploy.yaml.
+type Config struct {
+	Modules []Module `yaml:"modules"`
+}
+
+// LoadConfig reads and parses a deploy.yaml monorepo config from path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, m := range cfg.Modules {
+		if m.Name == "" || m.Path == "" {
+			return nil, fmt.Errorf("module %q: name and path are required", m.Name)
+		}
+		switch m.VersionStrategy {
+		case "", "auto", "patch", "minor", "major":
+		default:
+			return nil, fmt.Errorf("module %q: versionStrategy %q must be one of: auto, patch, minor, major", m.Name, m.VersionStrategy)
+		}
+	}
+	return &cfg, nil
+}
+
+// ByName looks up a module by name.
+func (c *Config) ByName(name string) (Module, bool) {
+	for _, m := range c.Modules {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Module{}, false
+}
+
+// TopoSort orders modules so that every module comes after the modules it
+// depends on. It returns an error if dependsOn names an unknown module or
+// if the dependency graph has a cycle.
+func (c *Config) TopoSort() ([]Module, error) {
+	// state: 0=unvisited 1=visiting 2=done
+	state := map[string]int{}
+	var order []Module
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 2:
+			return nil
+		case 1:
+			return fmt.Errorf("dependency cycle detected at module %q", name)
+		}
+		state[name] = 1
+		// Every caller of visit already validated name exists: the top-level
+		// loop below only calls it with cfg.Modules' own names, and the
+		// recursive call just below only follows a dep after confirming
+		// c.ByName(dep) succeeds.
+		m, _ := c.ByName(name)
+		for _, dep := range m.DependsOn {
+			if _, ok := c.ByName(dep); !ok {
+				return fmt.Errorf("module %q depends on unknown module %q", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, m)
+		return nil
+	}
+
+	for _, m := range c.Modules {
+		if err := visit(m.Name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
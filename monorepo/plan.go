@@ -0,0 +1,278 @@
+package monorepo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tsuyoshiwada/go-gitlog"
+
+	"deploy/publish"
+	"deploy/sv"
+)
+
+// ModulePlan is the computed release plan for a single module.
+type ModulePlan struct {
+	Module      Module
+	Dirty       bool
+	FromTag     string
+	NextTag     string
+	NextVersion string
+	Changelog   string
+}
+
+// fetchTags fetches all tags from origin so that a collaborator's
+// already-pushed "<module>/vX.Y.Z" tag is visible to lastTag, even if this
+// clone hasn't fetched since.
+func fetchTags() error {
+	_, err := exec.Command("git", "fetch", "--tags", "--force").Output()
+	return err
+}
+
+// moduleTags returns every "<name>/vX.Y.Z" tag for module, newest first.
+func moduleTags(name string) ([]string, error) {
+	out, err := exec.Command("git", "tag", "--list", name+"/v*", "--sort=-v:refname").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// lastTag returns the most recent "<name>/vX.Y.Z" tag for module, or "" if
+// it has never been tagged.
+func lastTag(name string) (string, error) {
+	tags, err := moduleTags(name)
+	if err != nil {
+		return "", err
+	}
+	if len(tags) == 0 {
+		return "", nil
+	}
+	return tags[0], nil
+}
+
+// changedFiles returns the set of files touched between fromTag and HEAD.
+// fromTag == "" means every tracked file.
+func changedFiles(fromTag string) ([]string, error) {
+	if fromTag == "" {
+		out, err := exec.Command("git", "ls-files").Output()
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+	}
+	out, err := exec.Command("git", "diff", "--name-only", fromTag, "HEAD").Output()
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// bumpSemver bumps the "vX.Y.Z" version of a module's last tag
+// (module/vX.Y.Z) by bump, starting at v0.1.0 if the module has never
+// been tagged.
+func bumpSemver(fromTag string, name string, bump sv.Bump) string {
+	if fromTag == "" {
+		return "v0.1.0"
+	}
+
+	major, minor, patch := 0, 0, 0
+	v := strings.TrimPrefix(fromTag, name+"/v")
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) == 3 {
+		fmt.Sscanf(parts[0], "%d", &major)
+		fmt.Sscanf(parts[1], "%d", &minor)
+		fmt.Sscanf(parts[2], "%d", &patch)
+	}
+
+	switch bump {
+	case sv.BumpMajor:
+		major, minor, patch = major+1, 0, 0
+	case sv.BumpMinor:
+		minor, patch = minor+1, 0
+	default:
+		patch++
+	}
+	return fmt.Sprintf("v%d.%d.%d", major, minor, patch)
+}
+
+func touchesPath(files []string, path string) bool {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for _, f := range files {
+		if f == path || strings.HasPrefix(f, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// commitsTouching returns the commits between fromTag and until that touch
+// path, in oldest-first order. until is usually "HEAD", but a release-retry
+// (the tag already exists, only its release is missing) passes the tag
+// itself so the regenerated changelog matches what was actually tagged.
+func commitsTouching(fromTag string, until string, path string) ([]*gitlog.Commit, error) {
+	args := []string{"log", "--pretty=format:%H"}
+	if fromTag != "" {
+		args = append(args, fromTag+".."+until)
+	} else {
+		args = append(args, until)
+	}
+	args = append(args, "--", path)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, h := range strings.Fields(string(out)) {
+		wanted[h] = true
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	git := gitlog.New(&gitlog.Config{})
+	var all []*gitlog.Commit
+	if fromTag == "" {
+		all, err = git.Log(nil, nil)
+	} else {
+		all, err = git.Log(&gitlog.RevRange{Old: fromTag, New: until}, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*gitlog.Commit
+	for _, c := range all {
+		if wanted[c.Hash.Long] {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
+
+// renderChangelog groups commits by Conventional Commit type, rendered the
+// same way for a normal dirty module and for a release-only retry.
+func renderChangelog(commits []*gitlog.Commit) string {
+	groups := sv.GroupByType(commits)
+	var b strings.Builder
+	for _, g := range groups {
+		fmt.Fprintf(&b, "### %s\n", g.Name)
+		for _, c := range g.Commits {
+			fmt.Fprintf(&b, "- %s %s (%s)\n", c.Hash.Short, c.Subject, c.Author.Name)
+		}
+	}
+	return b.String()
+}
+
+// Build computes the release plan for every module in the monorepo:
+// which ones are dirty since their last tag, their next version, and a
+// per-module changelog. It also catches modules left over by a previous,
+// partially-failed `deploy plan` run: if a module's last tag was pushed
+// but never got a release (publisher.CreateRelease failed or the process
+// died in between), it has no further file changes and so is no longer
+// "dirty" by diff alone - without this check it would silently never be
+// retried. Modules are returned in dependency order.
+func Build(cfg *Config, publisher publish.Publisher) ([]ModulePlan, error) {
+	if err := fetchTags(); err != nil {
+		return nil, fmt.Errorf("fetching tags: %w", err)
+	}
+
+	ordered, err := cfg.TopoSort()
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []ModulePlan
+	for _, m := range ordered {
+		tags, err := moduleTags(m.Name)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: resolving tags: %w", m.Name, err)
+		}
+		from := ""
+		if len(tags) > 0 {
+			from = tags[0]
+		}
+
+		changed, err := changedFiles(from)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: diffing since %s: %w", m.Name, from, err)
+		}
+		dirty := touchesPath(changed, m.Path)
+
+		plan := ModulePlan{Module: m, Dirty: dirty, FromTag: from}
+		if !dirty {
+			if from != "" {
+				released, err := publisher.ReleaseExists(context.Background(), from)
+				if err != nil {
+					return nil, fmt.Errorf("module %s: checking release %s: %w", m.Name, from, err)
+				}
+				if !released {
+					prevTag := ""
+					if len(tags) > 1 {
+						prevTag = tags[1]
+					}
+					commits, err := commitsTouching(prevTag, from, m.Path)
+					if err != nil {
+						return nil, fmt.Errorf("module %s: collecting commits: %w", m.Name, err)
+					}
+					plan.Dirty = true
+					plan.NextTag = from
+					plan.NextVersion = strings.TrimPrefix(from, m.Name+"/")
+					plan.Changelog = renderChangelog(commits)
+				}
+			}
+			plans = append(plans, plan)
+			continue
+		}
+
+		commits, err := commitsTouching(from, "HEAD", m.Path)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: collecting commits: %w", m.Name, err)
+		}
+
+		strategy := m.VersionStrategy
+		var bump sv.Bump
+		if strategy == "" || strategy == "auto" {
+			var found bool
+			bump, found = sv.Infer(commits)
+			if !found {
+				bump = sv.BumpPatch
+			}
+		} else {
+			bump = sv.Bump(strategy)
+		}
+		plan.NextVersion = bumpSemver(from, m.Name, bump)
+		plan.NextTag = m.Name + "/" + plan.NextVersion
+		plan.Changelog = renderChangelog(commits)
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// Dirty filters a plan down to the modules that actually changed.
+func Dirty(plans []ModulePlan) []ModulePlan {
+	var out []ModulePlan
+	for _, p := range plans {
+		if p.Dirty {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Preview renders the combined, per-module changelog shown before the
+// user is prompted to tag and release.
+func Preview(plans []ModulePlan) string {
+	var b strings.Builder
+	for _, p := range plans {
+		fmt.Fprintf(&b, "## %s (%s)\n\n%s\n", p.Module.Name, p.NextVersion, p.Changelog)
+	}
+	return b.String()
+}
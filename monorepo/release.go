@@ -0,0 +1,41 @@
+package monorepo
+
+import (
+	"context"
+	"os/exec"
+
+	"deploy/publish"
+)
+
+// Release tags and publishes a single module's plan. Tag creation and the
+// release happen as one unit: if pushing the tag fails, the local tag is
+// rolled back; if the release fails after the tag was pushed, the tag is
+// left in place (it is already published). Both steps are idempotent, so
+// retrying a ModulePlan returned by Build for a module whose tag exists
+// but whose release doesn't (see Build's release-retry check) picks up
+// right after the failed step instead of erroring on the already-pushed tag.
+func Release(p ModulePlan, publisher publish.Publisher) error {
+	tag := p.NextTag
+
+	if err := exec.Command("git", "rev-parse", tag).Run(); err != nil {
+		if _, err := exec.Command("git", "tag", tag).Output(); err != nil {
+			return err
+		}
+
+		if _, err := exec.Command("git", "push", "origin", tag).Output(); err != nil {
+			// Rollback: the tag was created locally but never reached origin.
+			_, _ = exec.Command("git", "tag", "-d", tag).Output()
+			return err
+		}
+	}
+
+	released, err := publisher.ReleaseExists(context.Background(), tag)
+	if err != nil {
+		return err
+	}
+	if released {
+		return nil
+	}
+
+	return publisher.CreateRelease(context.Background(), tag, tag, p.Changelog)
+}
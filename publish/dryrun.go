@@ -0,0 +1,23 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+)
+
+// DryRun prints what would be published instead of contacting a forge.
+type DryRun struct {
+	Opts Options
+}
+
+func (d *DryRun) CreateRelease(ctx context.Context, tag string, title string, body string) error {
+	fmt.Printf("[dry-run] would publish release %q (draft=%t, prerelease=%t) for tag %s:\n%s\n",
+		title, d.Opts.Draft, d.Opts.Prerelease, tag, body)
+	return nil
+}
+
+// ReleaseExists always reports false: nothing is ever actually published
+// in dry-run mode, so there's nothing to resume past.
+func (d *DryRun) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,109 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Gitea publishes releases via the Gitea REST API.
+type Gitea struct {
+	Opts    Options
+	Token   string
+	BaseURL string
+	Owner   string
+	Repo    string
+}
+
+func newGitea(opts Options) (*Gitea, error) {
+	token := os.Getenv("GITEA_TOKEN")
+	if token == "" {
+		return nil, errors.New("GITEA_TOKEN must be set to publish to Gitea")
+	}
+	baseURL := os.Getenv("GITEA_URL")
+	if baseURL == "" {
+		return nil, errors.New("GITEA_URL must be set to publish to Gitea")
+	}
+
+	remote, err := originURL()
+	if err != nil {
+		return nil, err
+	}
+	project, err := ownerRepo(remote)
+	if err != nil {
+		return nil, err
+	}
+	owner, repo, ok := strings.Cut(project, "/")
+	if !ok {
+		return nil, fmt.Errorf("can't split %q into owner/repo", project)
+	}
+
+	return &Gitea{
+		Opts:    opts,
+		Token:   token,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Owner:   owner,
+		Repo:    repo,
+	}, nil
+}
+
+func (g *Gitea) CreateRelease(ctx context.Context, tag string, title string, body string) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"tag_name":   tag,
+		"name":       title,
+		"body":       body,
+		"draft":      g.Opts.Draft,
+		"prerelease": g.Opts.Prerelease,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/repos/%s/%s/releases", g.BaseURL, g.Owner, g.Repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+g.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gitea release create failed with status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func (g *Gitea) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/repos/%s/%s/releases/tags/%s", g.BaseURL, g.Owner, g.Repo, tag)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "token "+g.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("gitea release lookup failed with status %d: %s", resp.StatusCode, respBody)
+	}
+	return true, nil
+}
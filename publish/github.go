@@ -0,0 +1,41 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// GitHub publishes releases via the `gh` CLI, same behavior the tool has
+// always had.
+type GitHub struct {
+	Opts Options
+}
+
+func (g *GitHub) CreateRelease(ctx context.Context, tag string, title string, body string) error {
+	args := []string{"release", "create", tag, "--notes", body, "-t", title}
+	if g.Opts.Draft {
+		args = append(args, "--draft")
+	}
+	if g.Opts.Prerelease {
+		args = append(args, "--prerelease")
+	}
+	_, err := exec.CommandContext(ctx, "gh", args...).Output()
+	return err
+}
+
+func (g *GitHub) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	err := exec.CommandContext(ctx, "gh", "release", "view", tag).Run()
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// `gh release view` exits non-zero for "not found" the same way it
+		// would for an auth/network failure, but distinguishing those isn't
+		// worth shelling out again just to parse stderr; treat any non-zero
+		// exit as "doesn't exist (yet)" and let CreateRelease surface real errors.
+		return false, nil
+	}
+	return false, err
+}
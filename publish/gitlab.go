@@ -0,0 +1,105 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// GitLab publishes releases via the GitLab REST API.
+type GitLab struct {
+	Opts    Options
+	Token   string
+	BaseURL string
+	Project string // "owner/repo"
+}
+
+func newGitLab(opts Options) (*GitLab, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, errors.New("GITLAB_TOKEN must be set to publish to GitLab")
+	}
+	remote, err := originURL()
+	if err != nil {
+		return nil, err
+	}
+	project, err := ownerRepo(remote)
+	if err != nil {
+		return nil, err
+	}
+	return &GitLab{
+		Opts:    opts,
+		Token:   token,
+		BaseURL: "https://" + remoteHost(remote),
+		Project: project,
+	}, nil
+}
+
+func (g *GitLab) CreateRelease(ctx context.Context, tag string, title string, body string) error {
+	// GitLab has no native draft flag; a prerelease is approximated with
+	// a release name suffix since there's no dedicated field either.
+	name := title
+	if g.Opts.Prerelease {
+		name += " (prerelease)"
+	}
+	if g.Opts.Draft {
+		name += " (draft)"
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"tag_name":    tag,
+		"name":        name,
+		"description": body,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v4/projects/%s/releases", g.BaseURL, url.PathEscape(g.Project))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gitlab release create failed with status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func (g *GitLab) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	endpoint := fmt.Sprintf("%s/api/v4/projects/%s/releases/%s", g.BaseURL, url.PathEscape(g.Project), url.PathEscape(tag))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("gitlab release lookup failed with status %d: %s", resp.StatusCode, respBody)
+	}
+	return true, nil
+}
@@ -0,0 +1,106 @@
+// Package publish creates remote releases for a tagged version, with one
+// implementation per forge (GitHub, GitLab, Gitea) plus a dry-run mode,
+// so the tool isn't locked to GitHub-only monorepos.
+package publish
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Publisher publishes a release for an already-pushed tag.
+type Publisher interface {
+	CreateRelease(ctx context.Context, tag string, title string, body string) error
+
+	// ReleaseExists reports whether a release already exists for tag, so
+	// callers (the workflow's publish-release task, on --resume) can skip
+	// re-publishing a release that succeeded on a prior, interrupted run.
+	ReleaseExists(ctx context.Context, tag string) (bool, error)
+}
+
+// Options are the release attributes every Publisher implementation
+// understands and translates to its own forge's equivalent.
+type Options struct {
+	Draft      bool
+	Prerelease bool
+}
+
+// New resolves a Publisher by name ("github", "gitlab", "gitea", "dry-run"
+// or "auto" to detect from the origin remote).
+func New(name string, opts Options) (Publisher, error) {
+	if name == "" || name == "auto" {
+		return Detect(opts)
+	}
+	switch name {
+	case "github":
+		return &GitHub{Opts: opts}, nil
+	case "gitlab":
+		return newGitLab(opts)
+	case "gitea":
+		return newGitea(opts)
+	case "dry-run":
+		return &DryRun{Opts: opts}, nil
+	default:
+		return nil, fmt.Errorf("unknown publisher %q, must be one of: auto, github, gitlab, gitea, dry-run", name)
+	}
+}
+
+// Detect picks a Publisher based on the origin remote's host.
+func Detect(opts Options) (Publisher, error) {
+	url, err := originURL()
+	if err != nil {
+		return nil, fmt.Errorf("detecting publisher from origin remote: %w", err)
+	}
+	switch {
+	case strings.Contains(url, "gitlab"):
+		return newGitLab(opts)
+	case strings.Contains(url, "gitea"):
+		return newGitea(opts)
+	default:
+		return &GitHub{Opts: opts}, nil
+	}
+}
+
+func originURL() (string, error) {
+	out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ownerRepo extracts "owner/repo" out of a git remote URL, whether it's
+// ssh-style (git@host:owner/repo.git) or https-style
+// (https://host/owner/repo.git).
+func ownerRepo(remoteURL string) (string, error) {
+	url := strings.TrimSuffix(remoteURL, ".git")
+	if idx := strings.Index(url, "://"); idx != -1 {
+		url = url[idx+3:]
+	} else if idx := strings.Index(url, "@"); idx != -1 {
+		url = url[idx+1:]
+		url = strings.Replace(url, ":", "/", 1)
+	}
+	parts := strings.SplitN(url, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("can't parse owner/repo out of remote URL %q", remoteURL)
+	}
+	return parts[1], nil
+}
+
+func remoteHost(remoteURL string) string {
+	url := strings.TrimSuffix(remoteURL, ".git")
+	if idx := strings.Index(url, "://"); idx != -1 {
+		url = url[idx+3:]
+	} else if idx := strings.Index(url, "@"); idx != -1 {
+		url = url[idx+1:]
+		if c := strings.Index(url, ":"); c != -1 {
+			return url[:c]
+		}
+	}
+	if c := strings.Index(url, "/"); c != -1 {
+		return url[:c]
+	}
+	return url
+}
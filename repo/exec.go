@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// ExecRepo is the pre-go-git implementation, kept as a fallback for
+// repositories go-git can't open and for signed tags, which need the
+// user's own git/gpg-agent setup.
+type ExecRepo struct {
+	Dir  string
+	Sign bool
+}
+
+func (e *ExecRepo) git(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = e.Dir
+	return cmd
+}
+
+func (e *ExecRepo) IsClean() (bool, error) {
+	res, err := e.git("status", "-s").Output()
+	if err != nil {
+		return false, err
+	}
+	return len(res) == 0, nil
+}
+
+func (e *ExecRepo) IsMasterOrMain() (bool, error) {
+	res, err := e.git("rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return false, err
+	}
+	branch := strings.TrimSpace(string(res))
+	return branch == "master" || branch == "main", nil
+}
+
+func (e *ExecRepo) LastCommit() (string, error) {
+	res, err := e.git("rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(res)), nil
+}
+
+func (e *ExecRepo) FetchTags() error {
+	_, err := e.git("fetch", "--tags", "--force").Output()
+	return err
+}
+
+func (e *ExecRepo) TagExists(tag string) error {
+	_, err := e.git("rev-parse", tag).Output()
+	return err
+}
+
+func (e *ExecRepo) CreateTag(tag string, message string) error {
+	args := []string{"tag", "-a", tag, "-m", message}
+	if e.Sign {
+		args = []string{"tag", "-s", tag, "-m", message}
+	}
+	_, err := e.git(args...).Output()
+	return err
+}
+
+func (e *ExecRepo) PushTag(tag string) error {
+	_, err := e.git("push", "origin", tag).Output()
+	return err
+}
+
+var _ Repo = (*ExecRepo)(nil)
@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// GoGit is the default Repo implementation, backed by go-git/go-git so
+// releases work without a local git binary and can be exercised in tests
+// against an in-memory repository.
+type GoGit struct {
+	repo *git.Repository
+
+	// dir backs the ExecRepo fallback FetchTags/PushTag use when go-git
+	// hits an auth error: go-git doesn't use the system credential
+	// helper/GCM/askpass the way the git binary does, so a remote that
+	// relies on one of those (the common case for HTTPS-hosted private
+	// repos) would otherwise get a bare 401/403 with no way to recover.
+	dir string
+}
+
+func openGoGit(dir string) (*GoGit, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+	return &GoGit{repo: r, dir: dir}, nil
+}
+
+// isAuthError reports whether err is go-git's way of saying the remote
+// rejected our (lack of) credentials, as opposed to a real network or
+// repository problem.
+func isAuthError(err error) bool {
+	return errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed)
+}
+
+func (g *GoGit) IsClean() (bool, error) {
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return false, err
+	}
+	return status.IsClean(), nil
+}
+
+func (g *GoGit) IsMasterOrMain() (bool, error) {
+	head, err := g.repo.Head()
+	if err != nil {
+		return false, err
+	}
+	branch := head.Name().Short()
+	return branch == "master" || branch == "main", nil
+}
+
+func (g *GoGit) LastCommit() (string, error) {
+	head, err := g.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+func (g *GoGit) FetchTags() error {
+	err := g.repo.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"refs/tags/*:refs/tags/*"},
+		Tags:       git.AllTags,
+		Force:      true,
+	})
+	if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	if isAuthError(err) {
+		return (&ExecRepo{Dir: g.dir}).FetchTags()
+	}
+	return err
+}
+
+func (g *GoGit) TagExists(tag string) error {
+	_, err := g.repo.Tag(tag)
+	return err
+}
+
+func (g *GoGit) CreateTag(tag string, message string) error {
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+
+	tagger, err := g.tagger()
+	if err != nil {
+		return err
+	}
+
+	_, err = g.repo.CreateTag(tag, head.Hash(), &git.CreateTagOptions{
+		Message: message,
+		Tagger:  tagger,
+	})
+	return err
+}
+
+func (g *GoGit) PushTag(tag string) error {
+	refSpec := config.RefSpec("refs/tags/" + tag + ":refs/tags/" + tag)
+	err := g.repo.Push(&git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{refSpec},
+	})
+	if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	if isAuthError(err) {
+		return (&ExecRepo{Dir: g.dir}).PushTag(tag)
+	}
+	return err
+}
+
+func (g *GoGit) tagger() (*object.Signature, error) {
+	// SystemScope merges system, global and local config (ConfigScoped only
+	// merges scopes >= the one requested, and LocalScope is the narrowest),
+	// matching the precedence `git` itself uses to resolve user.name/user.email.
+	cfg, err := g.repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+	return &object.Signature{
+		Name:  cfg.User.Name,
+		Email: cfg.User.Email,
+		When:  time.Now(),
+	}, nil
+}
+
+var _ Repo = (*GoGit)(nil)
@@ -0,0 +1,48 @@
+// Package repo provides the handful of git operations deploy needs,
+// backed by go-git so the tool doesn't depend on a local git binary,
+// with an exec.Command-based fallback for what go-git can't do on its
+// own (GPG-signed tags).
+package repo
+
+// Repo is the set of git operations a release needs.
+type Repo interface {
+	// IsClean reports whether the working tree has no uncommitted changes.
+	IsClean() (bool, error)
+	// IsMasterOrMain reports whether HEAD is on the master or main branch.
+	IsMasterOrMain() (bool, error)
+	// LastCommit returns the hash HEAD points at.
+	LastCommit() (string, error)
+	// FetchTags fetches all tags from the origin remote.
+	FetchTags() error
+	// TagExists returns nil if tag exists locally, an error otherwise.
+	TagExists(tag string) error
+	// CreateTag creates an annotated tag at HEAD.
+	CreateTag(tag string, message string) error
+	// PushTag pushes tag to the origin remote.
+	PushTag(tag string) error
+}
+
+// Options configure how a Repo is opened.
+type Options struct {
+	// Sign produces GPG-signed tags using the user's configured signing
+	// key. go-git doesn't drive the user's gpg-agent, so signed tags
+	// always fall back to the exec.Command implementation.
+	Sign bool
+}
+
+// Open returns a Repo for the repository at dir ("" means the process's
+// working directory), preferring the native go-git backend and falling
+// back to shelling out to git when that's not possible.
+func Open(dir string, opts Options) (Repo, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if opts.Sign {
+		return &ExecRepo{Dir: dir, Sign: true}, nil
+	}
+	gg, err := openGoGit(dir)
+	if err != nil {
+		return &ExecRepo{Dir: dir}, nil
+	}
+	return gg, nil
+}
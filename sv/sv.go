@@ -0,0 +1,121 @@
+// Package sv infers the next semantic version bump from Conventional
+// Commits (https://www.conventionalcommits.org) subjects and bodies.
+package sv
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tsuyoshiwada/go-gitlog"
+)
+
+// Bump is the kind of semver bump a set of commits calls for.
+type Bump string
+
+const (
+	BumpNone  Bump = ""
+	BumpPatch Bump = "patch"
+	BumpMinor Bump = "minor"
+	BumpMajor Bump = "major"
+)
+
+// rank returns how significant a bump is, so the highest one wins.
+func (b Bump) rank() int {
+	switch b {
+	case BumpMajor:
+		return 3
+	case BumpMinor:
+		return 2
+	case BumpPatch:
+		return 1
+	default:
+		return 0
+	}
+}
+
+var subjectRe = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?(!)?:\s*(.*)$`)
+
+var breakingFooterRe = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s*.+`)
+
+// typeBump maps a Conventional Commit type to the bump it implies on its own.
+var typeBump = map[string]Bump{
+	"fix":  BumpPatch,
+	"feat": BumpMinor,
+}
+
+// ParseCommit parses a commit subject and body as a Conventional Commit and
+// returns the commit type (e.g. "feat", "fix") and the bump it implies.
+// An empty type means the subject didn't match the Conventional Commit
+// format at all.
+func ParseCommit(subject string, body string) (typ string, bump Bump) {
+	matches := subjectRe.FindStringSubmatch(strings.TrimSpace(subject))
+	if matches == nil {
+		return "", BumpNone
+	}
+	typ = strings.ToLower(matches[1])
+	breaking := matches[3] == "!" || breakingFooterRe.MatchString(body)
+
+	bump = typeBump[typ]
+	if breaking {
+		bump = BumpMajor
+	}
+	return typ, bump
+}
+
+// Infer walks commits and returns the highest semver bump implied by their
+// subjects, plus whether any Conventional Commit was found at all.
+func Infer(commits []*gitlog.Commit) (Bump, bool) {
+	highest := BumpNone
+	found := false
+	for _, c := range commits {
+		typ, bump := ParseCommit(c.Subject, c.Body)
+		if typ == "" {
+			continue
+		}
+		found = true
+		if bump.rank() > highest.rank() {
+			highest = bump
+		}
+	}
+	return highest, found
+}
+
+// Group is a named set of commits sharing a changelog section, e.g.
+// "Features" or "Breaking Changes".
+type Group struct {
+	Name    string
+	Commits []*gitlog.Commit
+}
+
+// GroupByType buckets commits into the standard changelog sections
+// (Features, Fixes, Breaking Changes, Other), in that order, omitting
+// empty sections.
+func GroupByType(commits []*gitlog.Commit) []Group {
+	sections := []string{"Features", "Fixes", "Breaking Changes", "Other"}
+	buckets := map[string][]*gitlog.Commit{}
+
+	for _, c := range commits {
+		typ, bump := ParseCommit(c.Subject, c.Body)
+		var section string
+		switch {
+		case bump == BumpMajor:
+			section = "Breaking Changes"
+		case typ == "feat":
+			section = "Features"
+		case typ == "fix":
+			section = "Fixes"
+		default:
+			section = "Other"
+		}
+		buckets[section] = append(buckets[section], c)
+	}
+
+	var groups []Group
+	for _, name := range sections {
+		if len(buckets[name]) == 0 {
+			continue
+		}
+		groups = append(groups, Group{Name: name, Commits: buckets[name]})
+	}
+	return groups
+}
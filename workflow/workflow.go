@@ -0,0 +1,228 @@
+// Package workflow models a release as a DAG of named, idempotent tasks
+// whose progress is persisted to disk, so a failed release can be resumed
+// with `deploy --resume <tag>` instead of starting over.
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Status is a task's position in its lifecycle.
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// TaskState is the persisted record of one task's last run.
+type TaskState struct {
+	Status     Status    `json:"status"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+}
+
+// State is the on-disk, resumable progress of one release. Outputs lets
+// completed tasks hand values (the next release tag, the changelog body)
+// to later tasks, including across a resume.
+type State struct {
+	ID      string                 `json:"id"`
+	Tasks   map[string]*TaskState  `json:"tasks"`
+	Outputs map[string]interface{} `json:"outputs"`
+
+	path string
+}
+
+// StateDir is where per-release state files live.
+const StateDir = ".deploy/state"
+
+func statePath(id string) string {
+	return filepath.Join(StateDir, id+".json")
+}
+
+// NewState starts fresh progress tracking for a release identified by id
+// (a commit hash is a good choice before the release tag is known).
+func NewState(id string) *State {
+	return &State{
+		ID:      id,
+		Tasks:   map[string]*TaskState{},
+		Outputs: map[string]interface{}{},
+		path:    statePath(id),
+	}
+}
+
+// LoadState reads back a previously persisted release, by id or by its
+// eventual release tag (see Rename).
+func LoadState(id string) (*State, error) {
+	data, err := os.ReadFile(statePath(id))
+	if err != nil {
+		return nil, fmt.Errorf("reading state for %q: %w", id, err)
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	s.path = statePath(id)
+	return &s, nil
+}
+
+// Save persists the state to disk.
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o644)
+}
+
+// Rename moves the state to be addressable by a new id (e.g. once the
+// release tag has been computed) and removes the old file.
+func (s *State) Rename(newID string) error {
+	oldPath := s.path
+	s.ID = newID
+	s.path = statePath(newID)
+	if err := s.Save(); err != nil {
+		return err
+	}
+	if oldPath != s.path {
+		_ = os.Remove(oldPath)
+	}
+	return nil
+}
+
+// taskStderr extracts the real stderr of a failed task, so users can
+// inspect partial failures. A TaskFunc failure from exec.Command's Output()
+// wraps an *exec.ExitError whose Error() is just "exit status N"; the
+// actual command output lives on its Stderr field instead.
+func taskStderr(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
+
+// ErrAborted is returned by a TaskFunc to stop the workflow cleanly
+// without marking the in-flight task (or any after it) as failed — used
+// when the user declines a confirmation prompt.
+var ErrAborted = fmt.Errorf("workflow aborted")
+
+// TaskFunc does a task's work, reading/writing whatever it needs from
+// state.Outputs.
+type TaskFunc func(state *State) error
+
+// Task is one named, idempotent step of a release.
+type Task struct {
+	Name      string
+	DependsOn []string
+	Run       TaskFunc
+}
+
+// Workflow is a DAG of tasks.
+type Workflow struct {
+	Tasks []Task
+}
+
+func (w *Workflow) byName(name string) (Task, bool) {
+	for _, t := range w.Tasks {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Task{}, false
+}
+
+// order topologically sorts tasks by DependsOn.
+func (w *Workflow) order() ([]Task, error) {
+	state := map[string]int{} // 0=unvisited 1=visiting 2=done
+	var ordered []Task
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 2:
+			return nil
+		case 1:
+			return fmt.Errorf("dependency cycle detected at task %q", name)
+		}
+		state[name] = 1
+		t, ok := w.byName(name)
+		if !ok {
+			return fmt.Errorf("unknown task %q", name)
+		}
+		for _, dep := range t.DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, t)
+		return nil
+	}
+
+	for _, t := range w.Tasks {
+		if err := visit(t.Name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Run executes every task in dependency order, skipping ones already
+// marked done in state (the resume path). Progress is saved to disk
+// after every task so a later `deploy --resume` can pick up where this
+// run left off. A task returning ErrAborted stops the run cleanly.
+func (w *Workflow) Run(state *State) error {
+	ordered, err := w.order()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range ordered {
+		if ts, ok := state.Tasks[t.Name]; ok && ts.Status == StatusDone {
+			continue
+		}
+
+		ts := &TaskState{Status: StatusRunning, StartedAt: time.Now()}
+		state.Tasks[t.Name] = ts
+		if err := state.Save(); err != nil {
+			return err
+		}
+
+		runErr := t.Run(state)
+		ts.FinishedAt = time.Now()
+
+		if runErr == ErrAborted {
+			ts.Status = StatusPending
+			ts.Stderr = ""
+			_ = state.Save()
+			return ErrAborted
+		}
+		if runErr != nil {
+			ts.Status = StatusFailed
+			ts.Stderr = taskStderr(runErr)
+			_ = state.Save()
+			return fmt.Errorf("task %q: %w", t.Name, runErr)
+		}
+
+		ts.Status = StatusDone
+		if err := state.Save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}